@@ -0,0 +1,187 @@
+package graphql_next
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+type (
+	// BatchRequester collects Requests and sends them as a single
+	// GraphQL batch request (a JSON array of {query, variables}), as
+	// supported by Apollo Server, graphql-yoga and Hasura. The server's
+	// array of results is decoded back positionally, one Response per
+	// Request added.
+	BatchRequester[T any, E any] struct {
+		client *Client
+		reqs   []*Request
+	}
+)
+
+// NewBatchRequester makes a new BatchRequester for sending a batch of
+// requests to client's endpoint.
+func NewBatchRequester[T any, E any](client *Client) *BatchRequester[T, E] {
+	return &BatchRequester[T, E]{client: client}
+}
+
+// Add queues req for the next Execute call and returns its slot index,
+// which is the index of its Response in the slice Execute returns.
+func (b *BatchRequester[T, E]) Add(req *Request) int {
+	b.reqs = append(b.reqs, req)
+	return len(b.reqs) - 1
+}
+
+// Execute sends every queued Request as a single batch and returns their
+// Responses in the order they were added.
+func (b *BatchRequester[T, E]) Execute(ctx context.Context) ([]Response[T, E], error) {
+	batch := make([]requestQuery, len(b.reqs))
+	for i, req := range b.reqs {
+		batch[i] = requestQuery{Query: req.q, Variables: req.vars}
+	}
+
+	var body bytes.Buffer
+	if err := json.NewEncoder(&body).Encode(batch); err != nil {
+		return nil, err
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, b.client.endpoint, &body)
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Close = b.client.closeReq
+	httpReq.Header.Set("Content-Type", "application/json; charset=utf-8")
+	httpReq.Header.Set("Accept", "application/json; charset=utf-8")
+
+	httpRes, err := b.client.httpClient.Do(httpReq)
+	if err != nil {
+		return nil, err
+	}
+	defer httpRes.Body.Close()
+
+	respBody, err := io.ReadAll(httpRes.Body)
+	if err != nil {
+		if httpRes.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("%v: %v", ErrRequest, httpRes.StatusCode)
+		}
+		return nil, NewError(err, ErrReadBody)
+	}
+
+	var responses []Response[T, E]
+	if err = json.Unmarshal(respBody, &responses); err != nil {
+		return nil, NewError(err, ErrDecode)
+	}
+	return responses, nil
+}
+
+type (
+	// BatchingClient is a DataLoader-style aggregator: concurrent
+	// Request calls are buffered for up to window (or until maxBatch
+	// callers are waiting, whichever comes first), then dispatched as a
+	// single BatchRequester round-trip and fanned back out to each
+	// caller. A zero or negative maxBatch disables the count-based
+	// trigger, so only window governs dispatch.
+	BatchingClient[T any, E any] struct {
+		client   *Client
+		window   time.Duration
+		maxBatch int
+
+		mu      sync.Mutex
+		pending []*batchEntry[T, E]
+		timer   *time.Timer
+	}
+
+	batchEntry[T any, E any] struct {
+		req    *Request
+		result chan batchResult[T, E]
+	}
+
+	batchResult[T any, E any] struct {
+		res Response[T, E]
+		err error
+	}
+)
+
+// NewBatchingClient makes a BatchingClient that batches calls to
+// Request over client's endpoint.
+func NewBatchingClient[T any, E any](client *Client, window time.Duration, maxBatch int) *BatchingClient[T, E] {
+	return &BatchingClient[T, E]{client: client, window: window, maxBatch: maxBatch}
+}
+
+// Request enqueues req into the current batch and blocks until that
+// batch is dispatched and its Response is decoded, or ctx is done first.
+// If ctx is cancelled before dispatch, only this caller's slot is
+// removed from the pending batch; the rest proceed unaffected.
+func (b *BatchingClient[T, E]) Request(ctx context.Context, req *Request) (Response[T, E], error) {
+	entry := &batchEntry[T, E]{req: req, result: make(chan batchResult[T, E], 1)}
+	b.enqueue(entry)
+
+	select {
+	case res := <-entry.result:
+		return res.res, res.err
+	case <-ctx.Done():
+		b.dequeue(entry)
+		var zero Response[T, E]
+		return zero, ctx.Err()
+	}
+}
+
+func (b *BatchingClient[T, E]) enqueue(entry *batchEntry[T, E]) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.pending = append(b.pending, entry)
+	if len(b.pending) == 1 {
+		b.timer = time.AfterFunc(b.window, b.flush)
+	}
+	if b.maxBatch > 0 && len(b.pending) >= b.maxBatch {
+		if b.timer != nil {
+			b.timer.Stop()
+		}
+		go b.flush()
+	}
+}
+
+func (b *BatchingClient[T, E]) dequeue(entry *batchEntry[T, E]) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for i, e := range b.pending {
+		if e == entry {
+			b.pending = append(b.pending[:i], b.pending[i+1:]...)
+			return
+		}
+	}
+}
+
+func (b *BatchingClient[T, E]) flush() {
+	b.mu.Lock()
+	entries := b.pending
+	b.pending = nil
+	b.mu.Unlock()
+
+	if len(entries) == 0 {
+		return
+	}
+
+	batchRequester := NewBatchRequester[T, E](b.client)
+	for _, entry := range entries {
+		batchRequester.Add(entry.req)
+	}
+
+	responses, err := batchRequester.Execute(context.Background())
+	for i, entry := range entries {
+		switch {
+		case err != nil:
+			entry.result <- batchResult[T, E]{err: err}
+		case i >= len(responses):
+			entry.result <- batchResult[T, E]{err: fmt.Errorf("graphql: batch response missing slot %d", i)}
+		default:
+			entry.result <- batchResult[T, E]{res: responses[i]}
+		}
+	}
+}
@@ -0,0 +1,158 @@
+package graphql_next
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestBatchRequester(t *testing.T) {
+	t.Run("should send queued requests as one array and decode positionally", func(t *testing.T) {
+		var calls int
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			calls++
+			var batch []requestQuery
+			assert.NoError(t, json.NewDecoder(r.Body).Decode(&batch))
+			assert.Len(t, batch, 2)
+			assert.Equal(t, Query("query A {}"), batch[0].Query)
+			assert.Equal(t, Query("query B {}"), batch[1].Query)
+
+			responses := []testResponse{
+				{Data: testData{Something: "a"}},
+				{Data: testData{Something: "b"}},
+			}
+			b, err := json.Marshal(responses)
+			assert.NoError(t, err)
+			_, err = w.Write(b)
+			assert.NoError(t, err)
+		}))
+		defer server.Close()
+
+		client := NewClient(server.URL)
+		batch := NewBatchRequester[testData, any](client)
+		idxA := batch.Add(NewRequest("query A {}"))
+		idxB := batch.Add(NewRequest("query B {}"))
+		assert.Equal(t, 0, idxA)
+		assert.Equal(t, 1, idxB)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+		defer cancel()
+		responses, err := batch.Execute(ctx)
+		assert.NoError(t, err)
+		assert.Equal(t, 1, calls)
+		assert.Equal(t, "a", responses[idxA].Data.Something)
+		assert.Equal(t, "b", responses[idxB].Data.Something)
+	})
+}
+
+func TestBatchingClient(t *testing.T) {
+	t.Run("should batch concurrent calls once maxBatch is reached", func(t *testing.T) {
+		var calls int
+		var mu sync.Mutex
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			mu.Lock()
+			calls++
+			mu.Unlock()
+
+			var batch []requestQuery
+			assert.NoError(t, json.NewDecoder(r.Body).Decode(&batch))
+			assert.Len(t, batch, 3)
+
+			responses := make([]testResponse, len(batch))
+			for i := range batch {
+				responses[i] = testResponse{Data: testData{Something: fmt.Sprintf("resp-%d", i)}}
+			}
+			b, err := json.Marshal(responses)
+			assert.NoError(t, err)
+			_, err = w.Write(b)
+			assert.NoError(t, err)
+		}))
+		defer server.Close()
+
+		client := NewClient(server.URL)
+		batching := NewBatchingClient[testData, any](client, time.Hour, 3)
+
+		var wg sync.WaitGroup
+		results := make([]string, 3)
+		for i := 0; i < 3; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				res, err := batching.Request(context.Background(), NewRequest("query {}"))
+				assert.NoError(t, err)
+				results[i] = res.Data.Something
+			}(i)
+		}
+		wg.Wait()
+
+		mu.Lock()
+		defer mu.Unlock()
+		assert.Equal(t, 1, calls)
+		assert.ElementsMatch(t, []string{"resp-0", "resp-1", "resp-2"}, results)
+	})
+
+	t.Run("should flush after the window elapses without reaching maxBatch", func(t *testing.T) {
+		var calls int
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			calls++
+			b, err := json.Marshal([]testResponse{{Data: testData{Something: "solo"}}})
+			assert.NoError(t, err)
+			_, err = w.Write(b)
+			assert.NoError(t, err)
+		}))
+		defer server.Close()
+
+		client := NewClient(server.URL)
+		batching := NewBatchingClient[testData, any](client, 20*time.Millisecond, 10)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+		defer cancel()
+		res, err := batching.Request(ctx, NewRequest("query {}"))
+		assert.NoError(t, err)
+		assert.Equal(t, 1, calls)
+		assert.Equal(t, "solo", res.Data.Something)
+	})
+
+	t.Run("should only drop the cancelled caller's slot, not the whole batch", func(t *testing.T) {
+		var calls int
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			calls++
+			var batch []requestQuery
+			assert.NoError(t, json.NewDecoder(r.Body).Decode(&batch))
+			assert.Len(t, batch, 1)
+			b, err := json.Marshal([]testResponse{{Data: testData{Something: "survivor"}}})
+			assert.NoError(t, err)
+			_, err = w.Write(b)
+			assert.NoError(t, err)
+		}))
+		defer server.Close()
+
+		client := NewClient(server.URL)
+		batching := NewBatchingClient[testData, any](client, 50*time.Millisecond, 2)
+
+		var wg sync.WaitGroup
+		wg.Add(1)
+		var cancelledErr error
+		go func() {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+			defer cancel()
+			_, cancelledErr = batching.Request(ctx, NewRequest("query cancelled {}"))
+		}()
+		wg.Wait()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+		defer cancel()
+		res, err := batching.Request(ctx, NewRequest("query {}"))
+		assert.NoError(t, err)
+		assert.Equal(t, "survivor", res.Data.Something)
+		assert.Error(t, cancelledErr)
+		assert.Equal(t, 1, calls)
+	})
+}
@@ -1,6 +1,9 @@
 package graphql_next
 
-import "net/http"
+import (
+	"context"
+	"net/http"
+)
 
 type (
 	ClientOption func(*Client)
@@ -9,11 +12,31 @@ type (
 		Do(r *http.Request) (*http.Response, error)
 	}
 
+	// RequestFunc runs before a Request is encoded and sent. It may
+	// derive a new context (e.g. to thread a request ID or span through
+	// to a RoundTripMiddleware) and may mutate req, for example to inject
+	// an Authorization header.
+	RequestFunc func(ctx context.Context, req *Request) (context.Context, error)
+
+	// RoundTripMiddleware wraps an HTTPRequestDoer with another one, the
+	// way an http.RoundTripper wraps a Transport. Client composes the
+	// middlewares supplied via WithRoundTripMiddleware around its
+	// httpClient in the order they're given, so the first one is
+	// outermost.
+	RoundTripMiddleware func(HTTPRequestDoer) HTTPRequestDoer
+
 	Client struct {
-		httpClient       HTTPRequestDoer
-		endpoint         string
-		useMultipartForm bool
-		closeReq         bool
+		httpClient           HTTPRequestDoer
+		endpoint             string
+		useMultipartForm     bool
+		closeReq             bool
+		before               []RequestFunc
+		roundTripMiddleware  []RoundTripMiddleware
+		usePersistedQueries  bool
+		persistedQueryHasher func(Query) string
+		persistedQueryCache  PersistedQueryCache
+		persistedQueryGET    bool
+		retryPolicy          *RetryPolicy
 	}
 )
 
@@ -26,6 +49,9 @@ func NewClient(endpoint string, opts ...ClientOption) *Client {
 	if c.httpClient == nil {
 		c.httpClient = http.DefaultClient
 	}
+	for i := len(c.roundTripMiddleware) - 1; i >= 0; i-- {
+		c.httpClient = c.roundTripMiddleware[i](c.httpClient)
+	}
 	return c
 }
 
@@ -53,3 +79,23 @@ func ImmediatelyCloseReqBody() ClientOption {
 		client.closeReq = true
 	}
 }
+
+// WithBefore registers RequestFuncs that run, in order, on every Request
+// before it is encoded and sent. This is the place for cross-cutting
+// concerns that need the Request itself, such as auth token injection or
+// starting a tracing span.
+func WithBefore(fns ...RequestFunc) ClientOption {
+	return func(client *Client) {
+		client.before = append(client.before, fns...)
+	}
+}
+
+// WithRoundTripMiddleware wraps the Client's HTTPRequestDoer with the
+// given middlewares, in order, so the first one is outermost. Use this
+// for concerns that only need the outgoing http.Request, such as
+// metrics, logging, or retries.
+func WithRoundTripMiddleware(mws ...RoundTripMiddleware) ClientOption {
+	return func(client *Client) {
+		client.roundTripMiddleware = append(client.roundTripMiddleware, mws...)
+	}
+}
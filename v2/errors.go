@@ -3,14 +3,19 @@ package graphql_next
 import "github.com/pkg/errors"
 
 var (
-	ErrInvalidInput         = errors.New("cannot send files with PostFields option")
-	ErrCreateVariablesField = errors.New("create variables field")
-	ErrEncodeVariablesField = errors.New("encode variables")
-	ErrCreateFile           = errors.New("create form file")
-	ErrReadBody             = errors.New("read body")
-	ErrDecode               = errors.New("decode")
-	ErrCopy                 = errors.New("copy")
-	ErrRequest              = errors.New("graphql: server returned a non-200 status code")
+	ErrInvalidInput          = errors.New("cannot send files with PostFields option")
+	ErrCreateVariablesField  = errors.New("create variables field")
+	ErrEncodeVariablesField  = errors.New("encode variables")
+	ErrCreateOperationsField = errors.New("create operations field")
+	ErrEncodeOperationsField = errors.New("encode operations")
+	ErrCreateMapField        = errors.New("create map field")
+	ErrEncodeMapField        = errors.New("encode map")
+	ErrInvalidFilePath       = errors.New("invalid file variable path")
+	ErrCreateFile            = errors.New("create form file")
+	ErrReadBody              = errors.New("read body")
+	ErrDecode                = errors.New("decode")
+	ErrCopy                  = errors.New("copy")
+	ErrRequest               = errors.New("graphql: server returned a non-200 status code")
 )
 
 type (
@@ -0,0 +1,103 @@
+package graphql_next
+
+import "container/list"
+
+const defaultPersistedQueryCacheSize = 256
+
+type (
+	// PersistedQueryCache remembers which query hashes have previously
+	// been registered with the server, so callers can plug in their own
+	// storage (e.g. shared across processes). The server is always the
+	// source of truth for what it currently has registered: every
+	// request still probes with the hash alone first and only falls back
+	// to sending the full query if the server reports
+	// PERSISTED_QUERY_NOT_FOUND.
+	PersistedQueryCache interface {
+		Get(hash string) (Query, bool)
+		Set(hash string, q Query)
+	}
+
+	lruPersistedQueryCache struct {
+		size    int
+		entries map[string]*list.Element
+		order   *list.List
+	}
+
+	lruPersistedQueryEntry struct {
+		hash string
+		q    Query
+	}
+)
+
+// newLRUPersistedQueryCache is the default PersistedQueryCache used by
+// UsePersistedQueries when no WithPersistedQueryCache option is given.
+func newLRUPersistedQueryCache(size int) *lruPersistedQueryCache {
+	return &lruPersistedQueryCache{
+		size:    size,
+		entries: make(map[string]*list.Element, size),
+		order:   list.New(),
+	}
+}
+
+func (c *lruPersistedQueryCache) Get(hash string) (Query, bool) {
+	el, ok := c.entries[hash]
+	if !ok {
+		return "", false
+	}
+	c.order.MoveToFront(el)
+	return el.Value.(*lruPersistedQueryEntry).q, true
+}
+
+func (c *lruPersistedQueryCache) Set(hash string, q Query) {
+	if el, ok := c.entries[hash]; ok {
+		el.Value.(*lruPersistedQueryEntry).q = q
+		c.order.MoveToFront(el)
+		return
+	}
+	el := c.order.PushFront(&lruPersistedQueryEntry{hash: hash, q: q})
+	c.entries[hash] = el
+	if c.order.Len() > c.size {
+		oldest := c.order.Back()
+		if oldest != nil {
+			c.order.Remove(oldest)
+			delete(c.entries, oldest.Value.(*lruPersistedQueryEntry).hash)
+		}
+	}
+}
+
+// UsePersistedQueries opts a Client into the Apollo Automatic Persisted
+// Queries protocol: every request is first sent as just a sha256 hash of
+// the query (computed by hasher), and only retried with the full query
+// body if the server reports it doesn't know that hash yet. Successful
+// hashes are recorded, by default in an in-memory LRU, so callers with
+// their own PersistedQueryCache (e.g. one shared across processes) can
+// track which queries a server has registered.
+func UsePersistedQueries(hasher func(Query) string) ClientOption {
+	return func(client *Client) {
+		client.usePersistedQueries = true
+		client.persistedQueryHasher = hasher
+		if client.persistedQueryCache == nil {
+			client.persistedQueryCache = newLRUPersistedQueryCache(defaultPersistedQueryCacheSize)
+		}
+	}
+}
+
+// WithPersistedQueryCache overrides the default in-memory LRU used to
+// remember which query hashes the server already has registered. Call
+// this before UsePersistedQueries so the latter doesn't install its
+// default.
+func WithPersistedQueryCache(cache PersistedQueryCache) ClientOption {
+	return func(client *Client) {
+		client.persistedQueryCache = cache
+	}
+}
+
+// WithGETForPersistedQueries sends persisted queries as GET requests,
+// with query/variables/extensions as URL params, so they can benefit
+// from HTTP caching. Both the hash-only attempt and the full-query
+// fallback after PERSISTED_QUERY_NOT_FOUND are sent as GET.
+func WithGETForPersistedQueries() ClientOption {
+	return func(client *Client) {
+		client.persistedQueryGET = true
+	}
+}
@@ -8,10 +8,18 @@ import (
 type (
 	QueryVariables map[string]any
 
+	// File describes a file to be uploaded following the GraphQL
+	// multipart request spec (https://github.com/jaydenseric/graphql-multipart-request-spec).
+	//
+	// Field is the dotted path into the operation's variables that this
+	// file fills in, e.g. "variables.file" or "variables.files.0". The
+	// referenced variable is sent as null in the "operations" field and
+	// the file content is sent as its own form part, as the spec requires.
 	File struct {
-		Field string
-		Name  string
-		R     io.Reader
+		Field       string
+		Name        string
+		ContentType string
+		R           io.Reader
 	}
 
 	Request struct {
@@ -57,10 +65,17 @@ func (req *Request) Query() Query {
 // File sets a file to upload.
 // Files are only supported with a Client that was created with
 // the UseMultipartForm option.
-func (req *Request) File(fieldname, filename string, r io.Reader) {
+//
+// variablePath is the dotted path into the operation's variables that
+// this file fills in, e.g. "variables.file" or "variables.files.0". The
+// variable at that path is sent as null automatically. Missing
+// intermediate values are created as objects, not lists, so a list path
+// such as "variables.files.0" needs the list set with Var first.
+func (req *Request) File(variablePath, filename, contentType string, r io.Reader) {
 	req.files = append(req.files, File{
-		Field: fieldname,
-		Name:  filename,
-		R:     r,
+		Field:       variablePath,
+		Name:        filename,
+		ContentType: contentType,
+		R:           r,
 	})
 }
@@ -1,4 +1,4 @@
-package v2
+package graphql_next
 
 import (
 	"bytes"
@@ -9,13 +9,28 @@ import (
 	"io"
 	"mime/multipart"
 	"net/http"
+	"net/textproto"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
 )
 
 type (
 	Requester[T any, E any] struct {
 		client *Client
+		after  []ResponseFunc[T, E]
 	}
 
+	// RequesterOption configures a Requester[T, E]. It lives alongside
+	// ClientOption rather than on Client itself because ResponseFunc is
+	// parameterized over the same T, E as the Requester it runs on.
+	RequesterOption[T any, E any] func(*Requester[T, E])
+
+	// ResponseFunc runs, in order, on a successfully decoded Response
+	// before it is handed back to the caller.
+	ResponseFunc[T any, E any] func(ctx context.Context, res *Response[T, E]) error
+
 	requestQuery struct {
 		Query     Query          `json:"query"`
 		Variables QueryVariables `json:"variables"`
@@ -27,93 +42,245 @@ type (
 	}
 )
 
-func NewRequester[T any, E any](client *Client) *Requester[T, E] {
-	return &Requester[T, E]{
+func NewRequester[T any, E any](client *Client, opts ...RequesterOption[T, E]) *Requester[T, E] {
+	r := &Requester[T, E]{
 		client: client,
 	}
+	for _, optionFunc := range opts {
+		optionFunc(r)
+	}
+	return r
+}
+
+// WithAfter registers ResponseFuncs that run, in order, on every
+// successfully decoded Response before it is returned to the caller.
+func WithAfter[T any, E any](fns ...ResponseFunc[T, E]) RequesterOption[T, E] {
+	return func(r *Requester[T, E]) {
+		r.after = append(r.after, fns...)
+	}
 }
 
 func (r *Requester[T, E]) Request(ctx context.Context, req *Request) (Response[T, E], error) {
 	var (
 		res Response[T, E]
+		err error
 	)
 	if len(req.files) > 0 && !r.client.useMultipartForm {
 		return res, ErrInvalidInput
 	}
 
-	if r.client.useMultipartForm {
-		return r.requestMultipart(ctx, req)
+	switch {
+	case r.client.useMultipartForm:
+		res, err = r.requestMultipartWithRetry(ctx, req)
+	case r.client.usePersistedQueries:
+		if ctx, err = r.runBefore(ctx, req); err != nil {
+			return res, err
+		}
+		res, err = r.requestPersistedQuery(ctx, req)
+	default:
+		res, err = r.requestJSONWithRetry(ctx, req)
+	}
+	if err != nil {
+		return res, err
+	}
+
+	for _, after := range r.after {
+		if err = after(ctx, &res); err != nil {
+			return res, err
+		}
 	}
 
-	return r.requestJSON(ctx, req)
+	return res, nil
+}
+
+// runBefore runs the Client's before hooks, in order, threading ctx
+// through each. It's called once per attempt (not once per Request) so
+// that a RetryPolicy's TokenRefresher can refresh credentials and have a
+// before hook pick up the new value on the next attempt.
+func (r *Requester[T, E]) runBefore(ctx context.Context, req *Request) (context.Context, error) {
+	var err error
+	for _, before := range r.client.before {
+		if ctx, err = before(ctx, req); err != nil {
+			return ctx, err
+		}
+	}
+	return ctx, nil
 }
 
-func (r *Requester[T, E]) requestMultipart(ctx context.Context, req *Request) (Response[T, E], error) {
+// requestMultipart sends req following the GraphQL multipart request spec
+// (https://github.com/jaydenseric/graphql-multipart-request-spec), as
+// implemented by Apollo Server, graphql-yoga, gqlgen and Hasura: an
+// "operations" field carries the query/variables with null placeholders
+// where files belong, a "map" field says which form part fills in which
+// placeholder, and each file is its own form part named after its map key.
+func (r *Requester[T, E]) requestMultipart(ctx context.Context, req *Request) (Response[T, E], retryInfo, error) {
 	var (
-		request  bytes.Buffer
-		httpReq  *http.Request
-		httpRes  *http.Response
-		response Response[T, E]
-		err      error
+		request     bytes.Buffer
+		httpReq     *http.Request
+		httpRes     *http.Response
+		response    Response[T, E]
+		info        retryInfo
+		err         error
+		operations  requestQuery
+		fileMapping map[string][]string
 	)
+	if operations, fileMapping, err = buildMultipartOperations(req); err != nil {
+		return response, info, err
+	}
+
 	writer := multipart.NewWriter(&request)
-	if err = writer.WriteField("query", req.q.String()); err != nil {
-		return response, err
+
+	operationsField, err := writer.CreateFormField("operations")
+	if err != nil {
+		return response, info, NewError(err, ErrCreateOperationsField)
 	}
-	if len(req.vars) > 0 {
-		var (
-			variablesField io.Writer
-			variablesBuff  bytes.Buffer
-		)
-		if variablesField, err = writer.CreateFormField("variables"); err != nil {
-			return response, NewError(err, ErrCreateVariablesField)
-		}
-		if err = json.NewEncoder(io.MultiWriter(variablesField, &variablesBuff)).Encode(req.vars); err != nil {
-			return response, NewError(err, ErrEncodeVariablesField)
-		}
+	if err = json.NewEncoder(operationsField).Encode(operations); err != nil {
+		return response, info, NewError(err, ErrEncodeOperationsField)
+	}
+
+	mapField, err := writer.CreateFormField("map")
+	if err != nil {
+		return response, info, NewError(err, ErrCreateMapField)
 	}
+	if err = json.NewEncoder(mapField).Encode(fileMapping); err != nil {
+		return response, info, NewError(err, ErrEncodeMapField)
+	}
+
 	for i := range req.files {
-		part, err := writer.CreateFormFile(req.files[i].Field, req.files[i].Name)
+		file := req.files[i]
+		contentType := file.ContentType
+		if contentType == "" {
+			contentType = "application/octet-stream"
+		}
+		header := make(textproto.MIMEHeader)
+		header.Set("Content-Disposition", fmt.Sprintf(`form-data; name="%d"; filename="%s"`, i, file.Name))
+		header.Set("Content-Type", contentType)
+		part, err := writer.CreatePart(header)
 		if err != nil {
-			return response, NewError(err, ErrCreateFile)
+			return response, info, NewError(err, ErrCreateFile)
 		}
-		if _, err = io.Copy(part, req.files[i].R); err != nil {
-			return response, NewError(err, ErrCopy)
+		if _, err = io.Copy(part, file.R); err != nil {
+			return response, info, NewError(err, ErrCopy)
 		}
 	}
 	if err = writer.Close(); err != nil {
-		return response, errors.Wrap(err, "close writer")
+		return response, info, errors.Wrap(err, "close writer")
 	}
 
 	if httpReq, err = http.NewRequestWithContext(ctx, http.MethodPost, r.client.endpoint, &request); err != nil {
-		return response, err
+		return response, info, err
 	}
 
 	r.setRequestHeaders(httpReq, req, writer.FormDataContentType())
 
 	if httpRes, err = r.client.httpClient.Do(httpReq); err != nil {
-		return response, err
+		return response, info, err
 	}
 	defer httpRes.Body.Close()
+	info = newRetryInfo(httpRes)
 
 	body, err := io.ReadAll(httpRes.Body)
 	if err != nil {
 		if httpRes.StatusCode != http.StatusOK {
-			return response, fmt.Errorf("%v: %v", ErrRequest, httpRes.StatusCode)
+			return response, info, fmt.Errorf("%v: %v", ErrRequest, httpRes.StatusCode)
 		}
-		return response, NewError(err, ErrReadBody)
+		return response, info, NewError(err, ErrReadBody)
 	}
 
 	if err = json.Unmarshal(body, &response); err != nil {
-		return response, NewError(err, ErrDecode)
+		return response, info, NewError(err, ErrDecode)
 	}
 
-	return response, nil
+	return response, info, nil
+}
+
+// buildMultipartOperations assembles the "operations" payload (with null
+// placeholders at each file's variable path) and the "map" of form part
+// name to variable path, per the GraphQL multipart request spec.
+func buildMultipartOperations(req *Request) (requestQuery, map[string][]string, error) {
+	vars, err := cloneVariables(req.vars)
+	if err != nil {
+		return requestQuery{}, nil, NewError(err, ErrEncodeVariablesField)
+	}
+
+	fileMapping := make(map[string][]string, len(req.files))
+	for i := range req.files {
+		if err = nullVariableAtPath(vars, req.files[i].Field); err != nil {
+			return requestQuery{}, nil, err
+		}
+		fileMapping[strconv.Itoa(i)] = []string{req.files[i].Field}
+	}
+
+	return requestQuery{Query: req.q, Variables: QueryVariables(vars)}, fileMapping, nil
+}
+
+// cloneVariables deep copies vars via a JSON round-trip, so that nested
+// maps and slices can be mutated without affecting the caller's Request,
+// and so that slices become the []any form nullVariableAtPath expects.
+func cloneVariables(vars QueryVariables) (map[string]any, error) {
+	if vars == nil {
+		return map[string]any{}, nil
+	}
+	raw, err := json.Marshal(vars)
+	if err != nil {
+		return nil, err
+	}
+	clone := make(map[string]any)
+	if err = json.Unmarshal(raw, &clone); err != nil {
+		return nil, err
+	}
+	return clone, nil
+}
+
+// nullVariableAtPath sets the value at path (e.g. "variables.file" or
+// "variables.files.0") to nil within vars, creating intermediate maps as
+// needed. path must start with "variables.".
+func nullVariableAtPath(vars map[string]any, path string) error {
+	segments := strings.Split(path, ".")
+	if len(segments) < 2 || segments[0] != "variables" {
+		return errors.Wrap(ErrInvalidFilePath, fmt.Sprintf("%q must start with \"variables.\"", path))
+	}
+	segments = segments[1:]
+
+	var cur any = vars
+	for i, segment := range segments {
+		last := i == len(segments)-1
+		switch c := cur.(type) {
+		case map[string]any:
+			if last {
+				c[segment] = nil
+				return nil
+			}
+			next, ok := c[segment]
+			if !ok {
+				next = map[string]any{}
+				c[segment] = next
+			}
+			cur = next
+		case []any:
+			idx, err := strconv.Atoi(segment)
+			if err != nil || idx < 0 || idx >= len(c) {
+				return errors.Wrap(ErrInvalidFilePath, fmt.Sprintf("%q: index %q out of range", path, segment))
+			}
+			if last {
+				c[idx] = nil
+				return nil
+			}
+			cur = c[idx]
+		default:
+			return errors.Wrap(ErrInvalidFilePath, fmt.Sprintf("%q: cannot descend into %T", path, cur))
+		}
+	}
+	return nil
 }
 
 func (r *Requester[T, E]) setRequestHeaders(httpReq *http.Request, req *Request, contentType string) {
-	httpReq.Close = r.client.closeReq
+	r.setCommonHeaders(httpReq, req)
 	httpReq.Header.Set("Content-Type", contentType)
+}
+
+func (r *Requester[T, E]) setCommonHeaders(httpReq *http.Request, req *Request) {
+	httpReq.Close = r.client.closeReq
 	httpReq.Header.Set("Accept", "application/json; charset=utf-8")
 	for key, values := range req.Header {
 		for i := range values {
@@ -122,39 +289,292 @@ func (r *Requester[T, E]) setRequestHeaders(httpReq *http.Request, req *Request,
 	}
 }
 
-func (r *Requester[T, E]) requestJSON(ctx context.Context, req *Request) (Response[T, E], error) {
+func (r *Requester[T, E]) requestJSON(ctx context.Context, req *Request) (Response[T, E], retryInfo, error) {
 	var (
 		httpReq  *http.Request
 		httpRes  *http.Response
 		request  bytes.Buffer
 		response Response[T, E]
+		info     retryInfo
 		err      error
 	)
 	if err = json.NewEncoder(&request).Encode(requestQuery{Query: req.q, Variables: req.vars}); err != nil {
-		return response, err
+		return response, info, err
 	}
 	if httpReq, err = http.NewRequestWithContext(ctx, http.MethodPost, r.client.endpoint, &request); err != nil {
-		return response, err
+		return response, info, err
 	}
 
 	r.setRequestHeaders(httpReq, req, "application/json; charset=utf-8")
 
 	if httpRes, err = r.client.httpClient.Do(httpReq.WithContext(ctx)); err != nil {
-		return response, err
+		return response, info, err
 	}
 	defer httpRes.Body.Close()
+	info = newRetryInfo(httpRes)
 
 	body, err := io.ReadAll(httpRes.Body)
 	if err != nil {
-		return response, NewError(err, ErrReadBody)
+		return response, info, NewError(err, ErrReadBody)
 	}
 
 	if err = json.Unmarshal(body, &response); err != nil {
 		if httpRes.StatusCode != http.StatusOK {
-			return response, fmt.Errorf("%v: %v", ErrRequest, httpRes.StatusCode)
+			return response, info, fmt.Errorf("%v: %v", ErrRequest, httpRes.StatusCode)
+		}
+		return response, info, errors.Wrap(err, "decoding response")
+	}
+
+	return response, info, nil
+}
+
+// requestWithRetry runs send, and while the Client has a RetryPolicy
+// configured, keeps retrying per the policy's ShouldRetry decision until
+// it returns false, MaxAttempts is reached, or ctx is done. Between
+// attempts it sleeps for the policy's backoff and, when the policy has a
+// TokenRefresher, refreshes credentials first.
+func (r *Requester[T, E]) requestWithRetry(ctx context.Context, send func(ctx context.Context) (Response[T, E], retryInfo, error)) (Response[T, E], error) {
+	policy := r.client.retryPolicy
+	if policy == nil {
+		res, _, err := send(ctx)
+		return res, err
+	}
+
+	var (
+		res Response[T, E]
+		err error
+	)
+	maxAttempts := policy.MaxAttempts
+	if maxAttempts <= 0 {
+		maxAttempts = 1
+	}
+
+	for attempt := 1; attempt <= maxAttempts; attempt++ {
+		var info retryInfo
+		res, info, err = send(ctx)
+
+		retry, after := policy.shouldRetry(info, probeErrors(res))
+		if !retry || attempt == maxAttempts {
+			return res, err
+		}
+
+		if policy.TokenRefresher != nil {
+			if refreshErr := policy.TokenRefresher.Refresh(ctx); refreshErr != nil {
+				return res, refreshErr
+			}
+		}
+
+		select {
+		case <-time.After(policy.backoff(attempt, after)):
+		case <-ctx.Done():
+			return res, ctx.Err()
 		}
-		return response, errors.Wrap(err, "decoding response")
 	}
 
+	return res, err
+}
+
+// requestJSONWithRetry sends req as a plain JSON request, retrying per
+// the Client's RetryPolicy, if any. The before hooks run again on every
+// attempt, so a TokenRefresher paired with an auth before hook sees
+// fresh credentials on the retry.
+func (r *Requester[T, E]) requestJSONWithRetry(ctx context.Context, req *Request) (Response[T, E], error) {
+	return r.requestWithRetry(ctx, func(ctx context.Context) (Response[T, E], retryInfo, error) {
+		ctx, err := r.runBefore(ctx, req)
+		if err != nil {
+			var zero Response[T, E]
+			return zero, retryInfo{}, err
+		}
+		return r.requestJSON(ctx, req)
+	})
+}
+
+// requestMultipartWithRetry sends req as a multipart request, retrying
+// per the Client's RetryPolicy, if any. Each file is buffered once up
+// front so its reader can be rewound for every attempt, and the before
+// hooks run again on every attempt for the same reason as
+// requestJSONWithRetry.
+func (r *Requester[T, E]) requestMultipartWithRetry(ctx context.Context, req *Request) (Response[T, E], error) {
+	if r.client.retryPolicy == nil || len(req.files) == 0 {
+		ctx, err := r.runBefore(ctx, req)
+		if err != nil {
+			var zero Response[T, E]
+			return zero, err
+		}
+		res, _, err := r.requestMultipart(ctx, req)
+		return res, err
+	}
+
+	buffers := make([][]byte, len(req.files))
+	for i := range req.files {
+		data, err := io.ReadAll(req.files[i].R)
+		if err != nil {
+			var zero Response[T, E]
+			return zero, NewError(err, ErrReadBody)
+		}
+		buffers[i] = data
+	}
+
+	return r.requestWithRetry(ctx, func(ctx context.Context) (Response[T, E], retryInfo, error) {
+		ctx, err := r.runBefore(ctx, req)
+		if err != nil {
+			var zero Response[T, E]
+			return zero, retryInfo{}, err
+		}
+		for i := range req.files {
+			req.files[i].R = bytes.NewReader(buffers[i])
+		}
+		return r.requestMultipart(ctx, req)
+	})
+}
+
+const persistedQueryNotFoundCode = "PERSISTED_QUERY_NOT_FOUND"
+
+type (
+	persistedQueryExtension struct {
+		Version    int    `json:"version"`
+		Sha256Hash string `json:"sha256Hash"`
+	}
+
+	persistedQueryExtensions struct {
+		PersistedQuery persistedQueryExtension `json:"persistedQuery"`
+	}
+
+	persistedQueryPayload struct {
+		Extensions persistedQueryExtensions `json:"extensions"`
+		Variables  QueryVariables           `json:"variables"`
+		Query      Query                    `json:"query,omitempty"`
+	}
+
+	persistedQueryErrorExtensions struct {
+		Code string `json:"code"`
+	}
+)
+
+// requestPersistedQuery implements the Apollo Automatic Persisted
+// Queries protocol: the query hash is always sent alone first — the
+// server, not the client's cache, is the source of truth for what it
+// has registered. Only if the server reports PERSISTED_QUERY_NOT_FOUND
+// do we retry once with the full query body so it can register it for
+// next time. A hash is only recorded in the PersistedQueryCache once a
+// request using it comes back free of GraphQL errors, so a query that
+// never registers isn't remembered as registered.
+func (r *Requester[T, E]) requestPersistedQuery(ctx context.Context, req *Request) (Response[T, E], error) {
+	hash := r.client.persistedQueryHasher(req.q)
+
+	response, notFound, err := r.sendPersistedQuery(ctx, req, hash, false)
+	if err != nil {
+		return response, err
+	}
+	if notFound {
+		if response, _, err = r.sendPersistedQuery(ctx, req, hash, true); err != nil {
+			return response, err
+		}
+	}
+
+	if len(response.Errors) == 0 {
+		r.client.persistedQueryCache.Set(hash, req.q)
+	}
 	return response, nil
 }
+
+// sendPersistedQuery sends a single persisted-query attempt and reports
+// whether the server responded with PERSISTED_QUERY_NOT_FOUND.
+func (r *Requester[T, E]) sendPersistedQuery(ctx context.Context, req *Request, hash string, includeQuery bool) (Response[T, E], bool, error) {
+	var (
+		httpReq  *http.Request
+		httpRes  *http.Response
+		response Response[T, E]
+		err      error
+	)
+	extensions := persistedQueryExtensions{PersistedQuery: persistedQueryExtension{Version: 1, Sha256Hash: hash}}
+
+	if r.client.persistedQueryGET {
+		if httpReq, err = r.buildPersistedQueryGET(ctx, req, extensions, includeQuery); err != nil {
+			return response, false, err
+		}
+		r.setCommonHeaders(httpReq, req)
+	} else {
+		if httpReq, err = r.buildPersistedQueryPOST(ctx, req, extensions, includeQuery); err != nil {
+			return response, false, err
+		}
+		r.setRequestHeaders(httpReq, req, "application/json; charset=utf-8")
+	}
+
+	if httpRes, err = r.client.httpClient.Do(httpReq); err != nil {
+		return response, false, err
+	}
+	defer httpRes.Body.Close()
+
+	body, err := io.ReadAll(httpRes.Body)
+	if err != nil {
+		if httpRes.StatusCode != http.StatusOK {
+			return response, false, fmt.Errorf("%v: %v", ErrRequest, httpRes.StatusCode)
+		}
+		return response, false, NewError(err, ErrReadBody)
+	}
+
+	if err = json.Unmarshal(body, &response); err != nil {
+		return response, false, NewError(err, ErrDecode)
+	}
+
+	return response, isPersistedQueryNotFound(body), nil
+}
+
+func (r *Requester[T, E]) buildPersistedQueryPOST(ctx context.Context, req *Request, extensions persistedQueryExtensions, includeQuery bool) (*http.Request, error) {
+	payload := persistedQueryPayload{Extensions: extensions, Variables: req.vars}
+	if includeQuery {
+		payload.Query = req.q
+	}
+
+	var body bytes.Buffer
+	if err := json.NewEncoder(&body).Encode(payload); err != nil {
+		return nil, err
+	}
+	return http.NewRequestWithContext(ctx, http.MethodPost, r.client.endpoint, &body)
+}
+
+func (r *Requester[T, E]) buildPersistedQueryGET(ctx context.Context, req *Request, extensions persistedQueryExtensions, includeQuery bool) (*http.Request, error) {
+	extensionsJSON, err := json.Marshal(extensions)
+	if err != nil {
+		return nil, err
+	}
+
+	endpoint, err := url.Parse(r.client.endpoint)
+	if err != nil {
+		return nil, err
+	}
+
+	params := endpoint.Query()
+	params.Set("extensions", string(extensionsJSON))
+	if len(req.vars) > 0 {
+		variablesJSON, err := json.Marshal(req.vars)
+		if err != nil {
+			return nil, err
+		}
+		params.Set("variables", string(variablesJSON))
+	}
+	if includeQuery {
+		params.Set("query", req.q.String())
+	}
+	endpoint.RawQuery = params.Encode()
+
+	return http.NewRequestWithContext(ctx, http.MethodGet, endpoint.String(), nil)
+}
+
+// isPersistedQueryNotFound reports whether body is a GraphQL error
+// response carrying the Apollo APQ "PERSISTED_QUERY_NOT_FOUND" code.
+func isPersistedQueryNotFound(body []byte) bool {
+	var probe struct {
+		Errors []GraphError[persistedQueryErrorExtensions] `json:"errors"`
+	}
+	if err := json.Unmarshal(body, &probe); err != nil {
+		return false
+	}
+	for _, graphErr := range probe.Errors {
+		if graphErr.Extensions.Code == persistedQueryNotFoundCode {
+			return true
+		}
+	}
+	return false
+}
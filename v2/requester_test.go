@@ -1,13 +1,16 @@
 package graphql_next
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/stretchr/testify/assert"
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 )
@@ -197,3 +200,284 @@ func TestNewRequester(t *testing.T) {
 		assert.Equal(t, expectedData.Something, response.Data.Something)
 	})
 }
+
+// multipartSpecHandler parses an incoming request the way a GraphQL
+// multipart-spec server (Apollo Server, gqlgen, ...) would: read
+// "operations" and "map", then reunite each uploaded file with the
+// variable path its map entry names.
+func multipartSpecHandler(t *testing.T, response any) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPost, r.Method)
+		assert.True(t, strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data"))
+
+		err := r.ParseMultipartForm(1 << 20)
+		assert.NoError(t, err)
+
+		var operations struct {
+			Query     string         `json:"query"`
+			Variables map[string]any `json:"variables"`
+		}
+		assert.NoError(t, json.Unmarshal([]byte(r.FormValue("operations")), &operations))
+
+		var fileMap map[string][]string
+		assert.NoError(t, json.Unmarshal([]byte(r.FormValue("map")), &fileMap))
+
+		for field, paths := range fileMap {
+			fileHeader := r.MultipartForm.File[field][0]
+			f, err := fileHeader.Open()
+			assert.NoError(t, err)
+			content, err := io.ReadAll(f)
+			assert.NoError(t, err)
+
+			assert.Len(t, paths, 1)
+			segments := strings.Split(paths[0], ".")
+			assert.Equal(t, "variables", segments[0])
+			// the variable the file fills in must be null in "operations"
+			assert.Nil(t, operations.Variables[segments[1]])
+			// the file part itself must carry the uploaded content and filename
+			assert.Equal(t, fileHeader.Filename, string(content))
+		}
+
+		b, err := json.Marshal(response)
+		assert.NoError(t, err)
+		_, err = w.Write(b)
+		assert.NoError(t, err)
+	}
+}
+
+func TestRequester_MultipartSpec(t *testing.T) {
+	t.Run("should upload a single file per the multipart request spec", func(t *testing.T) {
+		responseData := testResponse{Data: testData{Something: "yes"}}
+		server := httptest.NewServer(multipartSpecHandler(t, responseData))
+		defer server.Close()
+
+		client := NewClient(server.URL, UseMultipartForm())
+		requester := NewRequester[testData, any](client)
+
+		req := NewRequest("mutation ($file: Upload!) { uploadFile(file: $file) }")
+		req.File("variables.file", "hello.txt", "text/plain", bytes.NewBufferString("hello.txt"))
+
+		ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+		defer cancel()
+		response, err := requester.Request(ctx, req)
+		assert.NoError(t, err)
+		assert.Equal(t, responseData.Data, response.Data)
+	})
+
+	t.Run("should upload multiple files and null each referenced variable", func(t *testing.T) {
+		responseData := testResponse{Data: testData{Something: "yes"}}
+		server := httptest.NewServer(multipartSpecHandler(t, responseData))
+		defer server.Close()
+
+		client := NewClient(server.URL, UseMultipartForm())
+		requester := NewRequester[testData, any](client)
+
+		req := NewRequest("mutation ($a: Upload!, $b: Upload!) { uploadFiles(a: $a, b: $b) }")
+		req.Var("a", "placeholder-a")
+		req.Var("b", "placeholder-b")
+		req.File("variables.a", "a.txt", "text/plain", bytes.NewBufferString("a.txt"))
+		req.File("variables.b", "b.txt", "text/plain", bytes.NewBufferString("b.txt"))
+
+		ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+		defer cancel()
+		response, err := requester.Request(ctx, req)
+		assert.NoError(t, err)
+		assert.Equal(t, responseData.Data, response.Data)
+	})
+}
+
+func TestRequester_Middleware(t *testing.T) {
+	t.Run("should run before funcs and let them mutate the request", func(t *testing.T) {
+		var calls int
+		responseData := testResponse{Data: testData{Something: "yes"}}
+		server := createHTTPTestServer[testResponse](t, &calls, `{"query":"query {}","variables":null}`+"\n", responseData, func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, "Bearer injected-token", r.Header.Get("Authorization"))
+		})
+		defer server.Close()
+
+		before := func(ctx context.Context, req *Request) (context.Context, error) {
+			req.Header.Set("Authorization", "Bearer injected-token")
+			return ctx, nil
+		}
+
+		client := NewClient(server.URL, WithBefore(before))
+		requester := NewRequester[testData, any](client)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+		defer cancel()
+		response, err := requester.Request(ctx, NewRequest("query {}"))
+		assert.NoError(t, err)
+		assert.Equal(t, calls, 1)
+		assert.Equal(t, responseData.Data, response.Data)
+	})
+
+	t.Run("should abort the request when a before func errors", func(t *testing.T) {
+		var calls int
+		server := createHTTPTestServer[testResponse](t, &calls, "", testResponse{}, noop)
+		defer server.Close()
+
+		wantErr := errors.New("token refresh failed")
+		before := func(ctx context.Context, req *Request) (context.Context, error) {
+			return ctx, wantErr
+		}
+
+		client := NewClient(server.URL, WithBefore(before))
+		requester := NewRequester[testData, any](client)
+
+		_, err := requester.Request(context.Background(), NewRequest("query {}"))
+		assert.ErrorIs(t, err, wantErr)
+		assert.Equal(t, calls, 0)
+	})
+
+	t.Run("should run after funcs on the decoded response", func(t *testing.T) {
+		var calls int
+		responseData := testResponse{Data: testData{Something: "yes"}}
+		server := createHTTPTestServer[testResponse](t, &calls, `{"query":"query {}","variables":null}`+"\n", responseData, noop)
+		defer server.Close()
+
+		var sawData string
+		after := func(ctx context.Context, res *Response[testData, any]) error {
+			sawData = res.Data.Something
+			res.Data.Something = "rewritten"
+			return nil
+		}
+
+		client := NewClient(server.URL)
+		requester := NewRequester[testData, any](client, WithAfter(after))
+
+		ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+		defer cancel()
+		response, err := requester.Request(ctx, NewRequest("query {}"))
+		assert.NoError(t, err)
+		assert.Equal(t, "yes", sawData)
+		assert.Equal(t, "rewritten", response.Data.Something)
+	})
+
+	t.Run("should compose round-trip middleware around the http client in order", func(t *testing.T) {
+		var calls int
+		responseData := testResponse{Data: testData{Something: "yes"}}
+		server := createHTTPTestServer[testResponse](t, &calls, `{"query":"query {}","variables":null}`+"\n", responseData, noop)
+		defer server.Close()
+
+		var order []string
+		trace := func(name string) RoundTripMiddleware {
+			return func(next HTTPRequestDoer) HTTPRequestDoer {
+				return doerFunc(func(r *http.Request) (*http.Response, error) {
+					order = append(order, name)
+					return next.Do(r)
+				})
+			}
+		}
+
+		client := NewClient(server.URL, WithRoundTripMiddleware(trace("outer"), trace("inner")))
+		requester := NewRequester[testData, any](client)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+		defer cancel()
+		_, err := requester.Request(ctx, NewRequest("query {}"))
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"outer", "inner"}, order)
+	})
+}
+
+type doerFunc func(r *http.Request) (*http.Response, error)
+
+func (f doerFunc) Do(r *http.Request) (*http.Response, error) {
+	return f(r)
+}
+
+func fixedHasher(hash string) func(Query) string {
+	return func(Query) string { return hash }
+}
+
+func TestRequester_PersistedQueries(t *testing.T) {
+	t.Run("should retry with the full query when the server hasn't seen the hash", func(t *testing.T) {
+		var calls int
+		responseData := testResponse{Data: testData{Something: "yes"}}
+
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			calls++
+			var body map[string]any
+			assert.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+
+			if calls == 2 {
+				assert.Equal(t, "query {}", body["query"])
+				b, err := json.Marshal(responseData)
+				assert.NoError(t, err)
+				_, err = w.Write(b)
+				assert.NoError(t, err)
+				return
+			}
+
+			// calls 1 and 3: the hash alone, whether or not the server
+			// has seen it before — the client always probes hash-only
+			// first and only falls back to the full query on a
+			// PERSISTED_QUERY_NOT_FOUND response.
+			_, hasQuery := body["query"]
+			assert.False(t, hasQuery)
+			if calls == 1 {
+				_, err := w.Write([]byte(`{"errors":[{"message":"not found","extensions":{"code":"PERSISTED_QUERY_NOT_FOUND"}}]}`))
+				assert.NoError(t, err)
+				return
+			}
+
+			b, err := json.Marshal(responseData)
+			assert.NoError(t, err)
+			_, err = w.Write(b)
+			assert.NoError(t, err)
+		}))
+		defer server.Close()
+
+		client := NewClient(server.URL, UsePersistedQueries(fixedHasher("fixed-hash")))
+		requester := NewRequester[testData, any](client)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+		defer cancel()
+		response, err := requester.Request(ctx, NewRequest("query {}"))
+		assert.NoError(t, err)
+		assert.Equal(t, 2, calls)
+		assert.Equal(t, responseData.Data, response.Data)
+
+		// the hash is now known to the server, but the client still
+		// probes hash-only first on the next request — it just doesn't
+		// need the PERSISTED_QUERY_NOT_FOUND fallback this time.
+		response, err = requester.Request(ctx, NewRequest("query {}"))
+		assert.NoError(t, err)
+		assert.Equal(t, 3, calls)
+		assert.Equal(t, responseData.Data, response.Data)
+	})
+
+	t.Run("should send registered queries as GET with extensions as a URL param", func(t *testing.T) {
+		responseData := testResponse{Data: testData{Something: "yes"}}
+
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, http.MethodGet, r.Method)
+			assert.Empty(t, r.URL.Query().Get("query"))
+
+			var extensions struct {
+				PersistedQuery struct {
+					Version    int    `json:"version"`
+					Sha256Hash string `json:"sha256Hash"`
+				} `json:"persistedQuery"`
+			}
+			assert.NoError(t, json.Unmarshal([]byte(r.URL.Query().Get("extensions")), &extensions))
+			assert.Equal(t, "fixed-hash", extensions.PersistedQuery.Sha256Hash)
+			assert.Equal(t, 1, extensions.PersistedQuery.Version)
+
+			b, err := json.Marshal(responseData)
+			assert.NoError(t, err)
+			_, err = w.Write(b)
+			assert.NoError(t, err)
+		}))
+		defer server.Close()
+
+		client := NewClient(server.URL, UsePersistedQueries(fixedHasher("fixed-hash")), WithGETForPersistedQueries())
+		requester := NewRequester[testData, any](client)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+		defer cancel()
+		response, err := requester.Request(ctx, NewRequest("query {}"))
+		assert.NoError(t, err)
+		assert.Equal(t, responseData.Data, response.Data)
+	})
+}
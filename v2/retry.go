@@ -0,0 +1,167 @@
+package graphql_next
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"time"
+)
+
+type (
+	// RetryPolicy governs whether and how a Requester retries a failed
+	// attempt. ShouldRetry inspects both the HTTP response (statusCode,
+	// retryAfter as parsed from a Retry-After header, if any) and the
+	// decoded GraphQL errors, so callers can key off extension codes such
+	// as Apollo's THROTTLED alongside the usual 429/502/503/504 statuses.
+	// A nil ShouldRetry falls back to DefaultShouldRetry.
+	RetryPolicy struct {
+		MaxAttempts    int
+		BaseDelay      time.Duration
+		MaxDelay       time.Duration
+		ShouldRetry    func(statusCode int, retryAfter time.Duration, errs []GraphError[json.RawMessage]) (retry bool, after time.Duration)
+		TokenRefresher TokenRefresher
+	}
+
+	// TokenRefresher refetches credentials between retry attempts, for
+	// example after a GraphQL error whose extension code is
+	// UNAUTHENTICATED. It runs after ShouldRetry decides to retry and
+	// before the backoff delay, so a WithBefore hook reading the
+	// refreshed token sees it on the next attempt.
+	TokenRefresher interface {
+		Refresh(ctx context.Context) error
+	}
+
+	// retryInfo carries the pieces of an HTTP response a RetryPolicy
+	// needs that aren't part of the decoded Response[T, E].
+	retryInfo struct {
+		statusCode int
+		retryAfter time.Duration
+	}
+)
+
+// WithRetry installs policy on every Requester built against this
+// Client, so both plain JSON and multipart requests are retried on
+// transient failures. Requests sent via UsePersistedQueries are not
+// retried.
+func WithRetry(policy RetryPolicy) ClientOption {
+	return func(client *Client) {
+		client.retryPolicy = &policy
+	}
+}
+
+// DefaultRetryPolicy is a RetryPolicy that retries up to maxAttempts
+// times using DefaultShouldRetry and exponential backoff with jitter,
+// starting at 100ms and capped at 5s.
+func DefaultRetryPolicy(maxAttempts int) RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts: maxAttempts,
+		BaseDelay:   100 * time.Millisecond,
+		MaxDelay:    5 * time.Second,
+		ShouldRetry: DefaultShouldRetry,
+	}
+}
+
+// DefaultShouldRetry retries HTTP 429, 502, 503 and 504 (honoring
+// retryAfter when the server sent one) and any GraphQL error whose
+// extensions carry the Apollo "THROTTLED" code.
+func DefaultShouldRetry(statusCode int, retryAfter time.Duration, errs []GraphError[json.RawMessage]) (bool, time.Duration) {
+	switch statusCode {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true, retryAfter
+	}
+	for _, graphErr := range errs {
+		var extensions persistedQueryErrorExtensions
+		if err := json.Unmarshal(graphErr.Extensions, &extensions); err != nil {
+			continue
+		}
+		if extensions.Code == "THROTTLED" {
+			return true, retryAfter
+		}
+	}
+	return false, 0
+}
+
+// shouldRetry delegates to p.ShouldRetry, falling back to
+// DefaultShouldRetry when none was configured.
+func (p RetryPolicy) shouldRetry(info retryInfo, errs []GraphError[json.RawMessage]) (bool, time.Duration) {
+	shouldRetry := p.ShouldRetry
+	if shouldRetry == nil {
+		shouldRetry = DefaultShouldRetry
+	}
+	return shouldRetry(info.statusCode, info.retryAfter, errs)
+}
+
+// backoff computes the delay before attempt (1-indexed), exponential in
+// attempt and jittered by up to +/-25%, honoring retryAfter and MaxDelay
+// when set.
+func (p RetryPolicy) backoff(attempt int, retryAfter time.Duration) time.Duration {
+	if retryAfter > 0 {
+		return p.capDelay(retryAfter)
+	}
+
+	delay := p.BaseDelay
+	if delay <= 0 {
+		delay = 100 * time.Millisecond
+	}
+	delay <<= attempt - 1
+
+	jitter := time.Duration(float64(delay) * (0.75 + 0.5*rand.Float64()))
+	return p.capDelay(jitter)
+}
+
+func (p RetryPolicy) capDelay(delay time.Duration) time.Duration {
+	if p.MaxDelay > 0 && delay > p.MaxDelay {
+		return p.MaxDelay
+	}
+	return delay
+}
+
+// newRetryInfo captures the status code and Retry-After header of res so
+// a RetryPolicy can inspect them after res.Body has been drained.
+func newRetryInfo(res *http.Response) retryInfo {
+	return retryInfo{
+		statusCode: res.StatusCode,
+		retryAfter: parseRetryAfter(res.Header),
+	}
+}
+
+// parseRetryAfter parses a Retry-After header in either its delay-seconds
+// or HTTP-date form, returning 0 if the header is absent or malformed.
+func parseRetryAfter(header http.Header) time.Duration {
+	value := header.Get("Retry-After")
+	if value == "" {
+		return 0
+	}
+	if seconds, err := parseRetryAfterSeconds(value); err == nil {
+		return time.Duration(seconds) * time.Second
+	}
+	if when, err := http.ParseTime(value); err == nil {
+		if delay := time.Until(when); delay > 0 {
+			return delay
+		}
+	}
+	return 0
+}
+
+func parseRetryAfterSeconds(value string) (int, error) {
+	var seconds int
+	_, err := fmt.Sscanf(value, "%d", &seconds)
+	return seconds, err
+}
+
+// probeErrors converts res.Errors into their json.RawMessage-extension
+// form via a JSON round-trip, the same type-erasure trick cloneVariables
+// uses, so a RetryPolicy can inspect extensions without knowing E.
+func probeErrors[T any, E any](res Response[T, E]) []GraphError[json.RawMessage] {
+	raw, err := json.Marshal(res.Errors)
+	if err != nil {
+		return nil
+	}
+	var errs []GraphError[json.RawMessage]
+	if err = json.Unmarshal(raw, &errs); err != nil {
+		return nil
+	}
+	return errs
+}
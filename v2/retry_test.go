@@ -0,0 +1,200 @@
+package graphql_next
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type testThrottledExtension struct {
+	Code string `json:"code"`
+}
+
+func TestRequester_Retry(t *testing.T) {
+	t.Run("should retry a 503 and honor Retry-After before succeeding", func(t *testing.T) {
+		var calls int
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			calls++
+			if calls == 1 {
+				w.Header().Set("Retry-After", "0")
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			b, err := json.Marshal(testResponse{Data: testData{Something: "recovered"}})
+			assert.NoError(t, err)
+			_, err = w.Write(b)
+			assert.NoError(t, err)
+		}))
+		defer server.Close()
+
+		client := NewClient(server.URL, WithRetry(DefaultRetryPolicy(3)))
+		requester := NewRequester[testData, any](client)
+
+		res, err := requester.Request(context.Background(), NewRequest("query {}"))
+		assert.NoError(t, err)
+		assert.Equal(t, "recovered", res.Data.Something)
+		assert.Equal(t, 2, calls)
+	})
+
+	t.Run("should give up and return the last response once MaxAttempts is reached", func(t *testing.T) {
+		var calls int
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			calls++
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}))
+		defer server.Close()
+
+		client := NewClient(server.URL, WithRetry(RetryPolicy{MaxAttempts: 2, BaseDelay: time.Millisecond}))
+		requester := NewRequester[testData, any](client)
+
+		_, err := requester.Request(context.Background(), NewRequest("query {}"))
+		assert.Error(t, err)
+		assert.Equal(t, 2, calls)
+	})
+
+	t.Run("should retry on a custom ShouldRetry keyed off a GraphQL extension code", func(t *testing.T) {
+		var calls int
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			calls++
+			if calls == 1 {
+				errs := []GraphError[testThrottledExtension]{
+					{Message: "slow down", Extensions: testThrottledExtension{Code: "THROTTLED"}},
+				}
+				b, err := json.Marshal(struct {
+					Errors []GraphError[testThrottledExtension] `json:"errors"`
+				}{Errors: errs})
+				assert.NoError(t, err)
+				_, err = w.Write(b)
+				assert.NoError(t, err)
+				return
+			}
+			b, err := json.Marshal(testResponse{Data: testData{Something: "ok"}})
+			assert.NoError(t, err)
+			_, err = w.Write(b)
+			assert.NoError(t, err)
+		}))
+		defer server.Close()
+
+		policy := RetryPolicy{
+			MaxAttempts: 2,
+			BaseDelay:   time.Millisecond,
+			ShouldRetry: func(statusCode int, retryAfter time.Duration, errs []GraphError[json.RawMessage]) (bool, time.Duration) {
+				for _, graphErr := range errs {
+					var ext testThrottledExtension
+					if json.Unmarshal(graphErr.Extensions, &ext) == nil && ext.Code == "THROTTLED" {
+						return true, 0
+					}
+				}
+				return false, 0
+			},
+		}
+		client := NewClient(server.URL, WithRetry(policy))
+		requester := NewRequester[testData, any](client)
+
+		res, err := requester.Request(context.Background(), NewRequest("query {}"))
+		assert.NoError(t, err)
+		assert.Equal(t, "ok", res.Data.Something)
+		assert.Equal(t, 2, calls)
+	})
+
+	t.Run("should refresh the token before retrying a 401 Unauthorized response", func(t *testing.T) {
+		var calls int
+		var authHeader string
+		var mu sync.Mutex
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			mu.Lock()
+			calls++
+			n := calls
+			mu.Unlock()
+
+			if n == 1 {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			mu.Lock()
+			authHeader = r.Header.Get("Authorization")
+			mu.Unlock()
+			b, err := json.Marshal(testResponse{Data: testData{Something: "ok"}})
+			assert.NoError(t, err)
+			_, err = w.Write(b)
+			assert.NoError(t, err)
+		}))
+		defer server.Close()
+
+		refresher := &stubTokenRefresher{token: "initial-token"}
+		policy := RetryPolicy{
+			MaxAttempts: 2,
+			BaseDelay:   time.Millisecond,
+			ShouldRetry: func(statusCode int, retryAfter time.Duration, errs []GraphError[json.RawMessage]) (bool, time.Duration) {
+				return statusCode == http.StatusUnauthorized, 0
+			},
+			TokenRefresher: refresher,
+		}
+		client := NewClient(server.URL, WithRetry(policy), WithBefore(func(ctx context.Context, req *Request) (context.Context, error) {
+			req.Header.Set("Authorization", "Bearer "+refresher.token)
+			return ctx, nil
+		}))
+		requester := NewRequester[testData, any](client)
+
+		res, err := requester.Request(context.Background(), NewRequest("query {}"))
+		assert.NoError(t, err)
+		assert.Equal(t, "ok", res.Data.Something)
+		assert.Equal(t, 1, refresher.calls)
+		assert.Equal(t, "Bearer refreshed-initial-token", authHeader)
+	})
+
+	t.Run("should rewind multipart file readers between attempts", func(t *testing.T) {
+		var calls int
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			calls++
+			err := r.ParseMultipartForm(1 << 20)
+			assert.NoError(t, err)
+			file, _, err := r.FormFile("0")
+			assert.NoError(t, err)
+			defer file.Close()
+			content := make([]byte, 8)
+			n, err := file.Read(content)
+			assert.NoError(t, err)
+			assert.Equal(t, "contents", string(content[:n]))
+
+			if calls == 1 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			b, err := json.Marshal(testResponse{Data: testData{Something: "uploaded"}})
+			assert.NoError(t, err)
+			_, err = w.Write(b)
+			assert.NoError(t, err)
+		}))
+		defer server.Close()
+
+		client := NewClient(server.URL, UseMultipartForm(), WithRetry(DefaultRetryPolicy(2)))
+		requester := NewRequester[testData, any](client)
+
+		req := NewRequest("mutation ($file: Upload!) { upload(file: $file) }")
+		req.File("variables.file", "a.txt", "text/plain", strings.NewReader("contents"))
+
+		res, err := requester.Request(context.Background(), req)
+		assert.NoError(t, err)
+		assert.Equal(t, "uploaded", res.Data.Something)
+		assert.Equal(t, 2, calls)
+	})
+}
+
+type stubTokenRefresher struct {
+	token string
+	calls int
+}
+
+func (s *stubTokenRefresher) Refresh(ctx context.Context) error {
+	s.calls++
+	s.token = fmt.Sprintf("refreshed-%s", s.token)
+	return nil
+}
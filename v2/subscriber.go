@@ -0,0 +1,249 @@
+package graphql_next
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/url"
+)
+
+type (
+	// Subscriber runs GraphQL subscriptions over the graphql-transport-ws
+	// subprotocol (https://github.com/enisdenjo/graphql-ws), as spoken by
+	// Apollo Server and graphql-ws.
+	Subscriber[T any, E any] struct {
+		client           *Client
+		dialer           Dialer
+		connectionParams map[string]any
+	}
+
+	// SubscribeOption configures a Subscriber[T, E]. It's parameterized
+	// the same way RequesterOption is. Nothing here needs T or E today,
+	// but keeping Subscriber's options alongside its
+	// type parameters leaves room for options that decode payloads (e.g.
+	// a custom connection_ack handler) without a breaking change later.
+	SubscribeOption[T any, E any] func(*Subscriber[T, E])
+
+	wsMessage struct {
+		ID      string          `json:"id,omitempty"`
+		Type    string          `json:"type"`
+		Payload json.RawMessage `json:"payload,omitempty"`
+	}
+
+	subscribePayload struct {
+		Query     Query          `json:"query"`
+		Variables QueryVariables `json:"variables"`
+	}
+)
+
+const (
+	gqlConnectionInit = "connection_init"
+	gqlConnectionAck  = "connection_ack"
+	gqlSubscribe      = "subscribe"
+	gqlNext           = "next"
+	gqlError          = "error"
+	gqlComplete       = "complete"
+)
+
+// NewSubscriber makes a new Subscriber capable of running GraphQL
+// subscriptions against client's endpoint. By default it dials with
+// NewDefaultDialer; use WithDialer to swap in gorilla/websocket or
+// nhooyr.io/websocket.
+func NewSubscriber[T any, E any](client *Client, opts ...SubscribeOption[T, E]) *Subscriber[T, E] {
+	s := &Subscriber[T, E]{
+		client: client,
+		dialer: NewDefaultDialer(),
+	}
+	for _, optionFunc := range opts {
+		optionFunc(s)
+	}
+	return s
+}
+
+// WithDialer overrides the Dialer used to open the WebSocket connection.
+func WithDialer[T any, E any](dialer Dialer) SubscribeOption[T, E] {
+	return func(s *Subscriber[T, E]) {
+		s.dialer = dialer
+	}
+}
+
+// WithConnectionParams sets the payload sent with the connection_init
+// frame, typically used to authenticate the subscription connection.
+func WithConnectionParams[T any, E any](params map[string]any) SubscribeOption[T, E] {
+	return func(s *Subscriber[T, E]) {
+		s.connectionParams = params
+	}
+}
+
+// Subscribe dials the Subscriber's endpoint, performs the
+// graphql-transport-ws handshake, and starts req as a subscription. The
+// returned channel receives one Response per "next" message and is
+// closed when the server sends "complete", the connection errors out,
+// or ctx is cancelled (which sends a "complete" frame back to the
+// server first). A terminal GraphQL error frame is delivered as the
+// last Response on the channel, with Data left zero and Errors set, the
+// same way any other GraphQL error response is represented.
+func (s *Subscriber[T, E]) Subscribe(ctx context.Context, req *Request) (<-chan Response[T, E], error) {
+	wsURL, err := toWebSocketURL(s.client.endpoint)
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := s.dialer.Dial(ctx, wsURL, req.Header)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = s.connectionInit(conn); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	id, err := generateSubscriptionID()
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	payload, err := json.Marshal(subscribePayload{Query: req.q, Variables: req.vars})
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	raw, err := json.Marshal(wsMessage{ID: id, Type: gqlSubscribe, Payload: payload})
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if err = conn.WriteMessage(TextMessage, raw); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	ch := make(chan Response[T, E])
+	go s.readLoop(ctx, conn, id, ch)
+	return ch, nil
+}
+
+func (s *Subscriber[T, E]) connectionInit(conn WSConn) error {
+	init := wsMessage{Type: gqlConnectionInit}
+	if s.connectionParams != nil {
+		payload, err := json.Marshal(s.connectionParams)
+		if err != nil {
+			return err
+		}
+		init.Payload = payload
+	}
+
+	raw, err := json.Marshal(init)
+	if err != nil {
+		return err
+	}
+	if err = conn.WriteMessage(TextMessage, raw); err != nil {
+		return err
+	}
+
+	_, data, err := conn.ReadMessage()
+	if err != nil {
+		return err
+	}
+	var ack wsMessage
+	if err = json.Unmarshal(data, &ack); err != nil {
+		return err
+	}
+	if ack.Type != gqlConnectionAck {
+		return fmt.Errorf("graphql: expected %q, got %q", gqlConnectionAck, ack.Type)
+	}
+	return nil
+}
+
+// readLoop dispatches incoming frames for id into ch until the
+// subscription completes, errors, or ctx is cancelled.
+func (s *Subscriber[T, E]) readLoop(ctx context.Context, conn WSConn, id string, ch chan<- Response[T, E]) {
+	defer close(ch)
+	defer conn.Close()
+
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		select {
+		case <-ctx.Done():
+			raw, err := json.Marshal(wsMessage{ID: id, Type: gqlComplete})
+			if err == nil {
+				_ = conn.WriteMessage(TextMessage, raw)
+			}
+			_ = conn.Close()
+		case <-stop:
+		}
+	}()
+
+	for {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+
+		var msg wsMessage
+		if err = json.Unmarshal(data, &msg); err != nil {
+			return
+		}
+		if msg.ID != "" && msg.ID != id {
+			continue
+		}
+
+		switch msg.Type {
+		case gqlNext:
+			var res Response[T, E]
+			if err = json.Unmarshal(msg.Payload, &res); err != nil {
+				return
+			}
+			select {
+			case ch <- res:
+			case <-ctx.Done():
+				return
+			}
+		case gqlError:
+			var res Response[T, E]
+			if err = json.Unmarshal(msg.Payload, &res.Errors); err != nil {
+				return
+			}
+			select {
+			case ch <- res:
+			case <-ctx.Done():
+			}
+			return
+		case gqlComplete:
+			return
+		}
+	}
+}
+
+// toWebSocketURL rewrites an http(s):// client endpoint into its ws(s)://
+// equivalent, leaving an already-ws(s) endpoint untouched.
+func toWebSocketURL(endpoint string) (string, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return "", err
+	}
+	switch u.Scheme {
+	case "http":
+		u.Scheme = "ws"
+	case "https":
+		u.Scheme = "wss"
+	case "ws", "wss":
+	default:
+		return "", fmt.Errorf("graphql: cannot derive a websocket url from endpoint scheme %q", u.Scheme)
+	}
+	return u.String(), nil
+}
+
+func generateSubscriptionID() (string, error) {
+	buf := make([]byte, 8)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
@@ -0,0 +1,226 @@
+package graphql_next
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// wsReadClientFrame reads a single, masked client frame the way a
+// graphql-transport-ws server would.
+func wsReadClientFrame(br *bufio.Reader) (int, []byte, error) {
+	first, err := br.ReadByte()
+	if err != nil {
+		return 0, nil, err
+	}
+	opcode := int(first & 0x0f)
+
+	second, err := br.ReadByte()
+	if err != nil {
+		return 0, nil, err
+	}
+	masked := second&0x80 != 0
+	length := int64(second & 0x7f)
+	switch length {
+	case 126:
+		var buf [2]byte
+		if _, err = io.ReadFull(br, buf[:]); err != nil {
+			return 0, nil, err
+		}
+		length = int64(buf[0])<<8 | int64(buf[1])
+	case 127:
+		var buf [8]byte
+		if _, err = io.ReadFull(br, buf[:]); err != nil {
+			return 0, nil, err
+		}
+		length = 0
+		for _, b := range buf {
+			length = length<<8 | int64(b)
+		}
+	}
+
+	var mask [4]byte
+	if masked {
+		if _, err = io.ReadFull(br, mask[:]); err != nil {
+			return 0, nil, err
+		}
+	}
+
+	payload := make([]byte, length)
+	if _, err = io.ReadFull(br, payload); err != nil {
+		return 0, nil, err
+	}
+	if masked {
+		for i := range payload {
+			payload[i] ^= mask[i%4]
+		}
+	}
+	return opcode, payload, nil
+}
+
+// wsWriteServerFrame writes a single, unmasked server frame the way a
+// graphql-transport-ws server would.
+func wsWriteServerFrame(w io.Writer, messageType int, data []byte) error {
+	var header bytes.Buffer
+	header.WriteByte(0x80 | byte(messageType))
+
+	length := len(data)
+	switch {
+	case length <= 125:
+		header.WriteByte(byte(length))
+	case length <= 65535:
+		header.WriteByte(126)
+		header.WriteByte(byte(length >> 8))
+		header.WriteByte(byte(length))
+	default:
+		header.WriteByte(127)
+		for i := 7; i >= 0; i-- {
+			header.WriteByte(byte(length >> (8 * i)))
+		}
+	}
+	if _, err := w.Write(header.Bytes()); err != nil {
+		return err
+	}
+	_, err := w.Write(data)
+	return err
+}
+
+// newSubscriptionTestServer performs the WebSocket upgrade handshake
+// over a hijacked connection and hands off to handle for the
+// graphql-transport-ws exchange (connection_init/connection_ack onwards),
+// acting as an in-process Apollo/graphql-ws-compatible server.
+func newSubscriptionTestServer(t *testing.T, handle func(br *bufio.Reader, conn net.Conn)) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get("Sec-WebSocket-Key")
+		hj, ok := w.(http.Hijacker)
+		assert.True(t, ok)
+		conn, rw, err := hj.Hijack()
+		assert.NoError(t, err)
+		defer conn.Close()
+
+		response := "HTTP/1.1 101 Switching Protocols\r\n" +
+			"Upgrade: websocket\r\n" +
+			"Connection: Upgrade\r\n" +
+			"Sec-WebSocket-Accept: " + acceptKey(key) + "\r\n" +
+			"Sec-WebSocket-Protocol: graphql-transport-ws\r\n\r\n"
+		_, err = rw.WriteString(response)
+		assert.NoError(t, err)
+		assert.NoError(t, rw.Flush())
+
+		handle(rw.Reader, conn)
+	}))
+}
+
+func readClientMessage(t *testing.T, br *bufio.Reader) wsMessage {
+	_, data, err := wsReadClientFrame(br)
+	assert.NoError(t, err)
+	var msg wsMessage
+	assert.NoError(t, json.Unmarshal(data, &msg))
+	return msg
+}
+
+func writeServerMessage(t *testing.T, conn net.Conn, msg wsMessage) {
+	raw, err := json.Marshal(msg)
+	assert.NoError(t, err)
+	assert.NoError(t, wsWriteServerFrame(conn, TextMessage, raw))
+}
+
+func TestSubscriber(t *testing.T) {
+	t.Run("should deliver next messages and close the channel on complete", func(t *testing.T) {
+		server := newSubscriptionTestServer(t, func(br *bufio.Reader, conn net.Conn) {
+			init := readClientMessage(t, br)
+			assert.Equal(t, gqlConnectionInit, init.Type)
+			writeServerMessage(t, conn, wsMessage{Type: gqlConnectionAck})
+
+			sub := readClientMessage(t, br)
+			assert.Equal(t, gqlSubscribe, sub.Type)
+
+			nextPayload, err := json.Marshal(testResponse{Data: testData{Something: "tick-1"}})
+			assert.NoError(t, err)
+			writeServerMessage(t, conn, wsMessage{ID: sub.ID, Type: gqlNext, Payload: nextPayload})
+			writeServerMessage(t, conn, wsMessage{ID: sub.ID, Type: gqlComplete})
+		})
+		defer server.Close()
+
+		client := NewClient(server.URL)
+		subscriber := NewSubscriber[testData, any](client)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		defer cancel()
+		ch, err := subscriber.Subscribe(ctx, NewRequest("subscription { ticks }"))
+		assert.NoError(t, err)
+
+		res, ok := <-ch
+		assert.True(t, ok)
+		assert.Equal(t, "tick-1", res.Data.Something)
+
+		_, ok = <-ch
+		assert.False(t, ok)
+	})
+
+	t.Run("should deliver a terminal error frame as a Response with Errors set", func(t *testing.T) {
+		server := newSubscriptionTestServer(t, func(br *bufio.Reader, conn net.Conn) {
+			readClientMessage(t, br)
+			writeServerMessage(t, conn, wsMessage{Type: gqlConnectionAck})
+
+			sub := readClientMessage(t, br)
+			errPayload, err := json.Marshal([]testExtendedError{
+				{Message: "boom", Extensions: testErrorExtension{Code: http.StatusInternalServerError}},
+			})
+			assert.NoError(t, err)
+			writeServerMessage(t, conn, wsMessage{ID: sub.ID, Type: gqlError, Payload: errPayload})
+		})
+		defer server.Close()
+
+		client := NewClient(server.URL)
+		subscriber := NewSubscriber[testData, testErrorExtension](client)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		defer cancel()
+		ch, err := subscriber.Subscribe(ctx, NewRequest("subscription { ticks }"))
+		assert.NoError(t, err)
+
+		res, ok := <-ch
+		assert.True(t, ok)
+		assert.Len(t, res.Errors, 1)
+		assert.Equal(t, "boom", res.Errors[0].Message)
+
+		_, ok = <-ch
+		assert.False(t, ok)
+	})
+
+	t.Run("should send connection params with connection_init", func(t *testing.T) {
+		server := newSubscriptionTestServer(t, func(br *bufio.Reader, conn net.Conn) {
+			init := readClientMessage(t, br)
+			var params map[string]any
+			assert.NoError(t, json.Unmarshal(init.Payload, &params))
+			assert.Equal(t, "Bearer token", params["authorization"])
+			writeServerMessage(t, conn, wsMessage{Type: gqlConnectionAck})
+
+			sub := readClientMessage(t, br)
+			writeServerMessage(t, conn, wsMessage{ID: sub.ID, Type: gqlComplete})
+		})
+		defer server.Close()
+
+		client := NewClient(server.URL)
+		subscriber := NewSubscriber[testData, any](client, WithConnectionParams[testData, any](map[string]any{
+			"authorization": "Bearer token",
+		}))
+
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		defer cancel()
+		ch, err := subscriber.Subscribe(ctx, NewRequest("subscription { ticks }"))
+		assert.NoError(t, err)
+
+		_, ok := <-ch
+		assert.False(t, ok)
+	})
+}
@@ -0,0 +1,269 @@
+package graphql_next
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/tls"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+// Message types mirror gorilla/websocket's constants so that a
+// *websocket.Conn already satisfies WSConn without an adapter.
+const (
+	TextMessage   = 1
+	BinaryMessage = 2
+	CloseMessage  = 8
+	PingMessage   = 9
+	PongMessage   = 10
+)
+
+type (
+	// WSConn is the minimal surface Subscriber needs from a WebSocket
+	// connection. Its method set matches gorilla/websocket.Conn and
+	// nhooyr.io/websocket's Conn closely enough that both can be used
+	// as-is or behind a thin wrapper.
+	WSConn interface {
+		WriteMessage(messageType int, data []byte) error
+		ReadMessage() (messageType int, p []byte, err error)
+		Close() error
+	}
+
+	// Dialer opens a WSConn to urlStr, sending header during the
+	// handshake. Swap in a Dialer backed by gorilla/websocket or
+	// nhooyr.io/websocket via WithDialer if the default, dependency-free
+	// client isn't enough (e.g. for compression or proxy support).
+	Dialer interface {
+		Dial(ctx context.Context, urlStr string, header http.Header) (WSConn, error)
+	}
+
+	defaultDialer struct{}
+
+	wsConn struct {
+		conn net.Conn
+		br   *bufio.Reader
+
+		// writeMu serializes writes to conn: ReadMessage answers pings
+		// with a pong inline, so it can run concurrently with a caller's
+		// own WriteMessage (e.g. Subscriber's ctx-cancellation watcher
+		// sending a "complete" frame) without this, their bytes could
+		// interleave on the wire and corrupt the frame stream.
+		writeMu sync.Mutex
+	}
+)
+
+// NewDefaultDialer returns the Dialer Subscriber uses when none is
+// supplied via WithDialer: a small dependency-free RFC 6455 client that
+// speaks single-frame text/binary messages, which is all the
+// graphql-transport-ws protocol needs.
+func NewDefaultDialer() Dialer {
+	return defaultDialer{}
+}
+
+func (defaultDialer) Dial(ctx context.Context, urlStr string, header http.Header) (WSConn, error) {
+	u, err := url.Parse(urlStr)
+	if err != nil {
+		return nil, err
+	}
+
+	var d net.Dialer
+	addr := u.Host
+	if !strings.Contains(addr, ":") {
+		if u.Scheme == "wss" {
+			addr += ":443"
+		} else {
+			addr += ":80"
+		}
+	}
+
+	conn, err := d.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme == "wss" {
+		conn = tls.Client(conn, &tls.Config{ServerName: u.Hostname()})
+	}
+
+	key, err := generateWSKey()
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, (&url.URL{Scheme: "http", Host: u.Host, Path: u.Path, RawQuery: u.RawQuery}).String(), nil)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	for k, values := range header {
+		for _, v := range values {
+			req.Header.Add(k, v)
+		}
+	}
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Sec-WebSocket-Key", key)
+	req.Header.Set("Sec-WebSocket-Version", "13")
+	req.Header.Set("Sec-WebSocket-Protocol", "graphql-transport-ws")
+
+	if err = req.Write(conn); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	br := bufio.NewReader(conn)
+	res, err := http.ReadResponse(br, req)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusSwitchingProtocols {
+		conn.Close()
+		return nil, fmt.Errorf("websocket: unexpected handshake status %v", res.StatusCode)
+	}
+	if want := acceptKey(key); res.Header.Get("Sec-WebSocket-Accept") != want {
+		conn.Close()
+		return nil, fmt.Errorf("websocket: invalid Sec-WebSocket-Accept")
+	}
+
+	return &wsConn{conn: conn, br: br}, nil
+}
+
+func generateWSKey() (string, error) {
+	raw := make([]byte, 16)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(raw), nil
+}
+
+const websocketGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+func acceptKey(key string) string {
+	h := sha1.New()
+	h.Write([]byte(key + websocketGUID))
+	return base64.StdEncoding.EncodeToString(h.Sum(nil))
+}
+
+// WriteMessage sends data as a single, masked client frame, as RFC 6455
+// requires of every frame a client sends. Writes are serialized so a
+// ReadMessage's inline pong reply can't interleave with a concurrent
+// caller-initiated write.
+func (c *wsConn) WriteMessage(messageType int, data []byte) error {
+	var header bytes.Buffer
+	header.WriteByte(0x80 | byte(messageType))
+
+	maskBit := byte(0x80)
+	length := len(data)
+	switch {
+	case length <= 125:
+		header.WriteByte(maskBit | byte(length))
+	case length <= 65535:
+		header.WriteByte(maskBit | 126)
+		header.WriteByte(byte(length >> 8))
+		header.WriteByte(byte(length))
+	default:
+		header.WriteByte(maskBit | 127)
+		for i := 7; i >= 0; i-- {
+			header.WriteByte(byte(length >> (8 * i)))
+		}
+	}
+
+	var mask [4]byte
+	if _, err := rand.Read(mask[:]); err != nil {
+		return err
+	}
+	header.Write(mask[:])
+
+	masked := make([]byte, length)
+	for i := range data {
+		masked[i] = data[i] ^ mask[i%4]
+	}
+
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	if _, err := c.conn.Write(header.Bytes()); err != nil {
+		return err
+	}
+	_, err := c.conn.Write(masked)
+	return err
+}
+
+// ReadMessage reads a single, unmasked server frame, transparently
+// answering pings with pongs and surfacing the first data frame.
+func (c *wsConn) ReadMessage() (int, []byte, error) {
+	for {
+		first, err := c.br.ReadByte()
+		if err != nil {
+			return 0, nil, err
+		}
+		opcode := int(first & 0x0f)
+
+		second, err := c.br.ReadByte()
+		if err != nil {
+			return 0, nil, err
+		}
+		length := int64(second & 0x7f)
+		switch length {
+		case 126:
+			var buf [2]byte
+			if _, err = readFull(c.br, buf[:]); err != nil {
+				return 0, nil, err
+			}
+			length = int64(buf[0])<<8 | int64(buf[1])
+		case 127:
+			var buf [8]byte
+			if _, err = readFull(c.br, buf[:]); err != nil {
+				return 0, nil, err
+			}
+			length = 0
+			for _, b := range buf {
+				length = length<<8 | int64(b)
+			}
+		}
+
+		payload := make([]byte, length)
+		if _, err = readFull(c.br, payload); err != nil {
+			return 0, nil, err
+		}
+
+		switch opcode {
+		case PingMessage:
+			if err = c.WriteMessage(PongMessage, payload); err != nil {
+				return 0, nil, err
+			}
+			continue
+		case PongMessage:
+			continue
+		default:
+			return opcode, payload, nil
+		}
+	}
+}
+
+func (c *wsConn) Close() error {
+	return c.conn.Close()
+}
+
+func readFull(br *bufio.Reader, buf []byte) (int, error) {
+	total := 0
+	for total < len(buf) {
+		n, err := br.Read(buf[total:])
+		total += n
+		if err != nil {
+			return total, err
+		}
+	}
+	return total, nil
+}